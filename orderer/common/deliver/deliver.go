@@ -0,0 +1,180 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package deliver implements the orderer side of the Deliver RPC: a client
+// opens a stream, sends one signed envelope carrying a SeekInfo describing
+// where to start and when to stop, and receives that channel's blocks from
+// the requested starting point onward.
+package deliver
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/orderer/consensus"
+	cb "github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric/protos/orderer"
+	"github.com/hyperledger/fabric/protos/utils"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("orderer/common/deliver")
+
+// errInvalidSeekPosition is returned when a SeekInfo's Start carries a
+// SeekPosition type this handler does not recognize.
+var errInvalidSeekPosition = errors.New("invalid seek position")
+
+// ChainSupport is everything the Handler needs from a single channel to
+// service a Deliver stream against it.
+type ChainSupport interface {
+	// Reader exposes the channel's current height and lets the handler wait
+	// for a block beyond it without polling.
+	Reader() consensus.BlockReader
+
+	// Block returns the block at the given number, or nil if number is
+	// beyond the channel's current height.
+	Block(number uint64) *cb.Block
+
+	// Authorize evaluates the channel's Readers policy against the signed
+	// envelope that opened this stream. The Handler calls it again before
+	// every block it sends, so a policy change committed mid-stream is
+	// honored rather than only checked once at stream open.
+	Authorize(env *cb.Envelope) error
+}
+
+// SupportManager looks up the ChainSupport for a channel ID.
+type SupportManager interface {
+	GetChain(chainID string) (ChainSupport, bool)
+}
+
+// Handler services the Deliver RPC.
+type Handler struct {
+	SupportManager SupportManager
+}
+
+// Handle reads one envelope per request from srv and streams blocks back for
+// it until its SeekInfo's Stop is passed, only then reading the next request.
+// A request with no reachable Stop is streamed until a send fails or the
+// client closes the stream; a new request sent meanwhile is not read.
+func (h *Handler) Handle(srv ab.Deliver_DeliverServer) error {
+	for {
+		env, err := srv.Recv()
+		if err != nil {
+			return err
+		}
+
+		if err := h.deliverBlocks(srv, env); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *Handler) deliverBlocks(srv ab.Deliver_DeliverServer, env *cb.Envelope) error {
+	chdr, err := utils.ChannelHeader(env)
+	if err != nil {
+		return sendStatus(srv, cb.Status_BAD_REQUEST)
+	}
+
+	chain, ok := h.SupportManager.GetChain(chdr.ChannelId)
+	if !ok {
+		return sendStatus(srv, cb.Status_NOT_FOUND)
+	}
+
+	if err := chain.Authorize(env); err != nil {
+		logger.Warningf("Rejecting deliver request for channel %s: %s", chdr.ChannelId, err)
+		return sendStatus(srv, cb.Status_FORBIDDEN)
+	}
+
+	seekInfo := &ab.SeekInfo{}
+	if err := utils.UnmarshalEnvelopePayload(env, seekInfo); err != nil {
+		return sendStatus(srv, cb.Status_BAD_REQUEST)
+	}
+
+	reader := chain.Reader()
+	cursor, err := startBlockNumber(seekInfo, reader.Height())
+	if err != nil {
+		return sendStatus(srv, cb.Status_BAD_REQUEST)
+	}
+
+	// One goroutine (this one) services the whole stream end to end; waiting
+	// for a future block parks it on WaitForBlock rather than spawning a new
+	// goroutine per poll, so a slow or far-ahead-seeking client costs this
+	// handler nothing beyond the one blocked goroutine it already has.
+	for {
+		if stopReached(seekInfo, cursor) {
+			return sendStatus(srv, cb.Status_SUCCESS)
+		}
+
+		if cursor >= reader.Height() {
+			if seekInfo.Behavior == ab.SeekInfo_FAIL_IF_NOT_READY {
+				return sendStatus(srv, cb.Status_NOT_FOUND)
+			}
+
+			select {
+			case <-reader.WaitForBlock(cursor + 1):
+			case <-srv.Context().Done():
+				return srv.Context().Err()
+			}
+			continue
+		}
+
+		// Re-evaluate access on every block, not just at stream open, so a
+		// policy update committed while this stream is parked takes effect
+		// immediately instead of only on the next stream.
+		if err := chain.Authorize(env); err != nil {
+			logger.Warningf("Revoking in-flight deliver stream for channel %s: %s", chdr.ChannelId, err)
+			return sendStatus(srv, cb.Status_FORBIDDEN)
+		}
+
+		block := chain.Block(cursor)
+		if block == nil {
+			return sendStatus(srv, cb.Status_SERVICE_UNAVAILABLE)
+		}
+
+		if err := srv.Send(&ab.DeliverResponse{
+			Type: &ab.DeliverResponse_Block{Block: block},
+		}); err != nil {
+			return err
+		}
+
+		cursor++
+	}
+}
+
+// startBlockNumber resolves seekInfo.Start against the channel's current
+// height into the first block number this stream should send.
+func startBlockNumber(seekInfo *ab.SeekInfo, height uint64) (uint64, error) {
+	switch start := seekInfo.Start.Type.(type) {
+	case *ab.SeekPosition_Oldest:
+		return 0, nil
+	case *ab.SeekPosition_Newest:
+		if height == 0 {
+			return 0, nil
+		}
+		return height - 1, nil
+	case *ab.SeekPosition_Specified:
+		return start.Specified.Number, nil
+	default:
+		return 0, errInvalidSeekPosition
+	}
+}
+
+// stopReached reports whether cursor is past a SeekSpecified seekInfo.Stop,
+// i.e. whether this stream has already sent everything it was asked for.
+// Only a SeekSpecified Stop is honored: for an Oldest or Newest Stop, or
+// none, it always returns false.
+func stopReached(seekInfo *ab.SeekInfo, cursor uint64) bool {
+	stop, ok := seekInfo.Stop.Type.(*ab.SeekPosition_Specified)
+	if !ok {
+		return false
+	}
+	return cursor > stop.Specified.Number
+}
+
+func sendStatus(srv ab.Deliver_DeliverServer, status cb.Status) error {
+	return srv.Send(&ab.DeliverResponse{
+		Type: &ab.DeliverResponse_Status{Status: status},
+	})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deliver
+
+import (
+	"testing"
+
+	ab "github.com/hyperledger/fabric/protos/orderer"
+)
+
+func seekOldest() *ab.SeekPosition {
+	return &ab.SeekPosition{Type: &ab.SeekPosition_Oldest{Oldest: &ab.SeekOldest{}}}
+}
+
+func seekNewest() *ab.SeekPosition {
+	return &ab.SeekPosition{Type: &ab.SeekPosition_Newest{Newest: &ab.SeekNewest{}}}
+}
+
+func seekSpecified(number uint64) *ab.SeekPosition {
+	return &ab.SeekPosition{Type: &ab.SeekPosition_Specified{Specified: &ab.SeekSpecified{Number: number}}}
+}
+
+func TestStartBlockNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   *ab.SeekPosition
+		height  uint64
+		want    uint64
+		wantErr bool
+	}{
+		{name: "oldest on empty channel", start: seekOldest(), height: 0, want: 0},
+		{name: "oldest on populated channel", start: seekOldest(), height: 10, want: 0},
+		{name: "newest on empty channel", start: seekNewest(), height: 0, want: 0},
+		{name: "newest on populated channel", start: seekNewest(), height: 10, want: 9},
+		{name: "specified", start: seekSpecified(5), height: 10, want: 5},
+		{name: "unrecognized seek position", start: &ab.SeekPosition{}, height: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seekInfo := &ab.SeekInfo{Start: tt.start}
+			got, err := startBlockNumber(seekInfo, tt.height)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("startBlockNumber() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopReached(t *testing.T) {
+	tests := []struct {
+		name   string
+		stop   *ab.SeekPosition
+		cursor uint64
+		want   bool
+	}{
+		{name: "no stop specified never reaches", stop: &ab.SeekPosition{}, cursor: 1000, want: false},
+		{name: "cursor before stop", stop: seekSpecified(5), cursor: 4, want: false},
+		{name: "cursor at stop", stop: seekSpecified(5), cursor: 5, want: false},
+		{name: "cursor past stop", stop: seekSpecified(5), cursor: 6, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seekInfo := &ab.SeekInfo{Stop: tt.stop}
+			if got := stopReached(seekInfo, tt.cursor); got != tt.want {
+				t.Errorf("stopReached() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
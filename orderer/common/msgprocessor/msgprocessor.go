@@ -35,6 +35,11 @@ const (
 	// ConfigUpdateMsg is the class of configuration related messages.
 	// Messages of this type should be processed by ProcessConfigUpdateMsg.
 	ConfigUpdateMsg
+
+	// ConfigMsg is the class of messages which are already wrapped CONFIG envelopes,
+	// the result of a previously processed ConfigUpdateMsg. Messages of this type
+	// should be processed by ProcessConfigMsg.
+	ConfigMsg
 )
 
 // Processor provides the methods necessary to classify and process any message which
@@ -51,4 +56,12 @@ type Processor interface {
 	// return the resulting config message and the configSeq the config was computed from.  If the config update message
 	// is invalid, an error is returned.
 	ProcessConfigUpdateMsg(env *cb.Envelope) (config *cb.Envelope, configSeq uint64, err error)
+
+	// ProcessConfigMsg takes an already computed CONFIG envelope, such as one produced by
+	// ProcessConfigUpdateMsg, and re-validates it against configSeq. If the sequence it was
+	// computed from is stale, the underlying CONFIG_UPDATE is extracted and re-applied to the
+	// current configuration. It returns the (possibly recomputed) config envelope, the configSeq
+	// it is now valid against, and nil on success, or an error if the embedded config update is
+	// no longer valid.
+	ProcessConfigMsg(env *cb.Envelope) (config *cb.Envelope, configSeq uint64, err error)
 }
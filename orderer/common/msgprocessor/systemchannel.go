@@ -7,28 +7,51 @@ SPDX-License-Identifier: Apache-2.0
 package msgprocessor
 
 import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
 	configtxapi "github.com/hyperledger/fabric/common/configtx/api"
 	cb "github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric/protos/orderer"
 	"github.com/hyperledger/fabric/protos/utils"
 )
 
+// ordererGroupKey and consensusTypeKey name the config group/value a
+// channel's ConsensusType is found under in its ChannelGroup.
+const (
+	ordererGroupKey  = "Orderer"
+	consensusTypeKey = "ConsensusType"
+)
+
 // SystemChannelSupport includes the resources needed for the SystemChannel processor.
 type SystemChannelSupport interface {
 	// NewChannelConfig creates a new template configuration manager
 	NewChannelConfig(env *cb.Envelope) (configtxapi.Manager, error)
 }
 
+// ConsensusTypeValidator checks that a consensus type name is one this
+// orderer has a plugin for, and that metadata deserializes for it. It is
+// implemented by orderer/consensus.Validator and injected here rather than
+// imported directly: orderer/consensus already depends on this package (to
+// embed Processor in ConsenterSupport), so msgprocessor importing it back
+// would be a package import cycle.
+type ConsensusTypeValidator interface {
+	ValidateConsensusType(consensusType string, metadata []byte) error
+}
+
 // SystemChannel implements the Processor interface for the system channel
 type SystemChannel struct {
 	*StandardChannel
-	systemChannelSupport SystemChannelSupport
+	systemChannelSupport   SystemChannelSupport
+	consensusTypeValidator ConsensusTypeValidator
 }
 
-// NewSystemChannel creates a new system channel message processor
-func NewSystemChannel(support StandardChannelSupport, systemChannelSupport SystemChannelSupport) *SystemChannel {
+// NewSystemChannel creates a new system channel message processor.
+func NewSystemChannel(support StandardChannelSupport, systemChannelSupport SystemChannelSupport, consensusTypeValidator ConsensusTypeValidator) *SystemChannel {
 	return &SystemChannel{
-		StandardChannel:      NewStandardChannel(support),
-		systemChannelSupport: systemChannelSupport,
+		StandardChannel:        NewStandardChannel(support),
+		systemChannelSupport:   systemChannelSupport,
+		consensusTypeValidator: consensusTypeValidator,
 	}
 }
 
@@ -78,6 +101,10 @@ func (s *SystemChannel) ProcessConfigUpdateMsg(envConfigUpdate *cb.Envelope) (co
 		return nil, 0, err
 	}
 
+	if err := s.validateConsensusType(newChannelConfigEnv); err != nil {
+		return nil, 0, err
+	}
+
 	newChannelEnvConfig, err := utils.CreateSignedEnvelope(cb.HeaderType_CONFIG, channelID, s.support.Signer(), newChannelConfigEnv, msgVersion, epoch)
 	if err != nil {
 		return nil, 0, err
@@ -92,3 +119,31 @@ func (s *SystemChannel) ProcessConfigUpdateMsg(envConfigUpdate *cb.Envelope) (co
 
 	return wrappedOrdererTransaction, s.support.Sequence(), nil
 }
+
+// validateConsensusType extracts the new channel's requested ConsensusType
+// and hands it to s.consensusTypeValidator, so a channel creation requesting
+// an unknown or misconfigured consensus implementation is rejected here
+// rather than failing later when this orderer is asked to actually handle
+// the new chain.
+func (s *SystemChannel) validateConsensusType(newChannelConfigEnv *cb.ConfigEnvelope) error {
+	ordererGroup, ok := newChannelConfigEnv.Config.ChannelGroup.Groups[ordererGroupKey]
+	if !ok {
+		return fmt.Errorf("new channel config has no %s group", ordererGroupKey)
+	}
+
+	consensusTypeValue, ok := ordererGroup.Values[consensusTypeKey]
+	if !ok {
+		return fmt.Errorf("new channel config's %s group has no %s value", ordererGroupKey, consensusTypeKey)
+	}
+
+	consensusType := &ab.ConsensusType{}
+	if err := proto.Unmarshal(consensusTypeValue.Value, consensusType); err != nil {
+		return fmt.Errorf("failed unmarshaling ConsensusType: %s", err)
+	}
+
+	if err := s.consensusTypeValidator.ValidateConsensusType(consensusType.Type, consensusType.Metadata); err != nil {
+		return fmt.Errorf("new channel's consensus type is invalid: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package consensus
+
+import "sync"
+
+// BlockWaiter implements BlockReader with one channel per awaited height,
+// closed once a Notify reaches that height, so any number of waiters can
+// block on WaitForBlock without polling and without one waiter missing a
+// signal that fired before it started waiting. A Chain implementation
+// embeds one and calls Notify after every WriteBlock/WriteConfigBlock.
+type BlockWaiter struct {
+	mutex   sync.Mutex
+	height  uint64
+	waiters map[uint64]chan struct{}
+}
+
+// NewBlockWaiter creates a BlockWaiter whose initial height is the ledger's
+// height at chain construction time.
+func NewBlockWaiter(height uint64) *BlockWaiter {
+	return &BlockWaiter{height: height, waiters: map[uint64]chan struct{}{}}
+}
+
+// Height returns the most recently notified height.
+func (w *BlockWaiter) Height() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.height
+}
+
+// WaitForBlock returns a channel that closes once height reaches seq.
+func (w *BlockWaiter) WaitForBlock(seq uint64) <-chan struct{} {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.height >= seq {
+		ready := make(chan struct{})
+		close(ready)
+		return ready
+	}
+
+	signal, ok := w.waiters[seq]
+	if !ok {
+		signal = make(chan struct{})
+		w.waiters[seq] = signal
+	}
+	return signal
+}
+
+// Notify records that the ledger is now at height and wakes every waiter
+// whose awaited height it reaches. Waiters for a height still beyond the new
+// one keep waiting.
+func (w *BlockWaiter) Notify(height uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.height = height
+	for seq, signal := range w.waiters {
+		if seq <= height {
+			close(signal)
+			delete(w.waiters, seq)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package consensus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockWaiterHeight(t *testing.T) {
+	w := NewBlockWaiter(3)
+	if got := w.Height(); got != 3 {
+		t.Fatalf("Height() = %d, want 3", got)
+	}
+}
+
+func TestBlockWaiterAlreadyReached(t *testing.T) {
+	w := NewBlockWaiter(5)
+
+	select {
+	case <-w.WaitForBlock(5):
+	default:
+		t.Fatal("WaitForBlock(5) should already be closed when height is 5")
+	}
+
+	select {
+	case <-w.WaitForBlock(3):
+	default:
+		t.Fatal("WaitForBlock(3) should already be closed when height is 5")
+	}
+}
+
+func TestBlockWaiterNotify(t *testing.T) {
+	w := NewBlockWaiter(0)
+
+	waiting := w.WaitForBlock(1)
+	select {
+	case <-waiting:
+		t.Fatal("WaitForBlock(1) should not be closed before Notify(1)")
+	default:
+	}
+
+	w.Notify(1)
+
+	select {
+	case <-waiting:
+	case <-time.After(time.Second):
+		t.Fatal("WaitForBlock(1) did not unblock after Notify(1)")
+	}
+
+	if got := w.Height(); got != 1 {
+		t.Errorf("Height() = %d, want 1", got)
+	}
+}
+
+func TestBlockWaiterNotifyWakesMultipleWaiters(t *testing.T) {
+	w := NewBlockWaiter(0)
+
+	const numWaiters = 5
+	done := make(chan struct{}, numWaiters)
+	for i := 0; i < numWaiters; i++ {
+		go func() {
+			<-w.WaitForBlock(1)
+			done <- struct{}{}
+		}()
+	}
+
+	// Give every goroutine a chance to start waiting before notifying.
+	time.Sleep(10 * time.Millisecond)
+	w.Notify(1)
+
+	for i := 0; i < numWaiters; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d/%d waiters woke up", i, numWaiters)
+		}
+	}
+}
+
+func TestBlockWaiterDoesNotWakeWaiterForLaterBlock(t *testing.T) {
+	w := NewBlockWaiter(0)
+
+	waiting := w.WaitForBlock(2)
+	w.Notify(1)
+
+	select {
+	case <-waiting:
+		t.Fatal("WaitForBlock(2) should not unblock on Notify(1)")
+	default:
+	}
+
+	w.Notify(2)
+
+	select {
+	case <-waiting:
+	case <-time.After(time.Second):
+		t.Fatal("WaitForBlock(2) did not unblock after Notify(2)")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package consensus defines the interfaces binding an ordering plugin
+// (solo, kafka, etcdraft, ...) to the rest of the orderer. A plugin
+// implements Consenter and Chain; the orderer provides each chain with a
+// ConsenterSupport so the plugin never has to reach into ledger, config, or
+// block-cutting internals directly.
+package consensus
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/orderer/common/msgprocessor"
+	cb "github.com/hyperledger/fabric/protos/common"
+)
+
+// Consenter is implemented by each ordering plugin. HandleChain is called
+// once per channel, either at bootstrap or when a new channel is created,
+// and returns the Chain that will actually order that channel's envelopes.
+// metadata is whatever this plugin previously persisted for the channel (via
+// the Metadata field of a written block), or nil for a brand new channel.
+type Consenter interface {
+	HandleChain(support ConsenterSupport, metadata *cb.Metadata) (Chain, error)
+
+	// ValidateMetadata reports whether metadata deserializes into whatever
+	// format this plugin expects from a channel's ConsensusType.Metadata.
+	// It is called while processing the CONFIG_UPDATE that creates a
+	// channel, before that channel's config is allowed to commit, so a
+	// malformed or unsupported metadata value is rejected up front rather
+	// than failing later when HandleChain is finally called for it. A
+	// CONFIG_UPDATE to an existing channel is not checked with it.
+	ValidateMetadata(metadata []byte) error
+}
+
+// Chain is implemented by each ordering plugin's per-channel driver. Start
+// and Halt bracket its lifecycle; Order and Configure submit envelopes for
+// ordering, returning as soon as the plugin has accepted responsibility for
+// them, not once they are actually committed.
+type Chain interface {
+	// Order accepts a normal message for ordering. configSeq is the
+	// configuration sequence number the caller validated the message
+	// against, so a stale message can be revalidated before being cut.
+	Order(env *cb.Envelope, configSeq uint64) error
+
+	// Configure accepts a config update and its resulting, already-computed
+	// config envelope for ordering, under the same configSeq contract as
+	// Order.
+	Configure(configUpdate *cb.Envelope, config *cb.Envelope, configSeq uint64) error
+
+	// Errored returns a channel which closes when the chain halts, whether
+	// due to an error or a deliberate Halt call.
+	Errored() <-chan struct{}
+
+	// Start allocates whatever resources are needed to begin ordering and
+	// returns immediately; ordering happens on a goroutine the chain manages
+	// itself.
+	Start()
+
+	// Halt frees the resources allocated by Start and may be called more
+	// than once without effect.
+	Halt()
+
+	// Reader returns a BlockReader over this chain's ledger, letting a
+	// deliver stream discover the current height and block without polling.
+	Reader() BlockReader
+}
+
+// BlockReader lets a consumer such as a deliver stream learn a channel's
+// current height and wait for a future block to be written, without
+// polling the ledger.
+type BlockReader interface {
+	// Height returns the number of blocks currently in the channel's ledger.
+	Height() uint64
+
+	// WaitForBlock returns a channel which closes once the channel's ledger
+	// height is at least seq. If it already is, the returned channel is
+	// already closed.
+	WaitForBlock(seq uint64) <-chan struct{}
+}
+
+// BlockCutter buffers ordered but not yet cut messages for a channel and
+// decides, message by message, when enough have accumulated to fill a block.
+type BlockCutter interface {
+	// Ordered accepts a message which has already passed ProcessNormalMsg.
+	// It returns the batches that are ready to be cut (zero, one, or - if
+	// the message alone exceeds the preferred size - more than one), and
+	// whether the message was accepted into a pending, not-yet-full batch.
+	Ordered(msg *cb.Envelope) (messageBatches [][]*cb.Envelope, pending bool)
+
+	// Cut returns and clears the currently pending batch, nil if empty.
+	Cut() []*cb.Envelope
+}
+
+// Orderer exposes the subset of a channel's orderer configuration a
+// consensus plugin needs to make scheduling decisions.
+type Orderer interface {
+	// BatchTimeout is the maximum amount of time to wait before cutting a
+	// pending, not-yet-full batch into a block.
+	BatchTimeout() time.Duration
+
+	// ConsensusMetadata returns this channel's current ConsensusType.Metadata,
+	// the plugin-specific configuration (e.g. an etcdraft chain's consenter
+	// set) a Chain decodes itself rather than the orderer parsing it for
+	// every plugin it might be handling.
+	ConsensusMetadata() []byte
+}
+
+// ConsenterSupport gives a Chain everything it needs from the rest of the
+// orderer without depending on its internals directly: message
+// classification and validation (via msgprocessor.Processor), the
+// channel's block cutter and orderer config, and the means to extend the
+// channel's ledger.
+type ConsenterSupport interface {
+	msgprocessor.Processor
+
+	// BlockCutter returns the block cutter for this channel.
+	BlockCutter() BlockCutter
+
+	// SharedConfig returns this channel's current orderer configuration.
+	SharedConfig() Orderer
+
+	// CreateNextBlock constructs a new block from the given messages,
+	// filling in its header from the current ledger height, but does not
+	// append it to the ledger.
+	CreateNextBlock(messages []*cb.Envelope) *cb.Block
+
+	// WriteBlock appends block to the channel's ledger. encodedMetadataValue,
+	// when non-nil, replaces Metadata[BlockMetadataIndex_ORDERER] so a
+	// consensus plugin can persist its own resumption state.
+	WriteBlock(block *cb.Block, encodedMetadataValue []byte)
+
+	// WriteConfigBlock is like WriteBlock, but additionally updates the
+	// channel's current configuration to the one carried by block.
+	WriteConfigBlock(block *cb.Block, encodedMetadataValue []byte)
+
+	// ChainID returns the channel ID this support serves.
+	ChainID() string
+
+	// Height returns the number of blocks in the channel's ledger.
+	Height() uint64
+
+	// Sequence returns the current configuration sequence number for this
+	// channel, so a Chain can tell whether a message validated against an
+	// older configSeq needs to be re-validated before being cut.
+	Sequence() uint64
+}
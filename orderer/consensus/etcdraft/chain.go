@@ -0,0 +1,422 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package etcdraft
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric/orderer/consensus"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+)
+
+// snapshotInterval is the Raft log index interval between snapshots: one is
+// taken whenever the last entry applied from a Ready batch has an index that
+// is a multiple of it. Snapshotting bounds how much of the WAL a restarting or newly joining node
+// has to replay, at the cost of periodically serializing the ledger height.
+const snapshotInterval = 100
+
+// chain implements consensus.Chain by driving a go.etcd.io/etcd/raft.Node.
+// Order/Configure propose the envelope to the Raft group; main drains
+// node.Ready() and, once an entry is committed, every replica - leader and
+// followers alike - feeds it into the block cutter exactly as solo.chain.main
+// does. BlockCutter is deterministic given the same sequence of committed
+// entries, so every replica cuts and writes byte-for-byte identical blocks;
+// the only things reserved to the leader are deciding *when* a pending
+// batch's timeout has elapsed, which it does by proposing a cut marker
+// through Raft rather than cutting locally, so that decision is applied
+// identically too, and proposing membership changes (reconcileConsenters).
+type chain struct {
+	support consensus.ConsenterSupport
+
+	id uint64
+
+	propC chan *proposal // Envelopes pending proposal to Raft
+	haltC chan struct{}
+	exitC chan struct{}
+
+	node      raft.Node
+	storage   *raft.MemoryStorage
+	wal       *wal.WAL
+	peers     []raft.Peer // Initial membership; only used when restart is false
+	restart   bool        // Whether the WAL already existed, i.e. this is a restart, not a bootstrap
+	transport Transport
+
+	lastRaftIndex uint64
+	confState     raftpb.ConfState
+
+	waiter *consensus.BlockWaiter
+}
+
+func newChain(support consensus.ConsenterSupport, id uint64, walDir string, metadata *RaftMetadata, peers []raft.Peer, transport Transport) (*chain, error) {
+	storage := raft.MemoryStorage{}
+
+	w, restart, err := openWAL(walDir, &storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL at %s: %s", walDir, err)
+	}
+
+	return &chain{
+		support:       support,
+		id:            id,
+		propC:         make(chan *proposal),
+		haltC:         make(chan struct{}),
+		exitC:         make(chan struct{}),
+		storage:       &storage,
+		wal:           w,
+		peers:         peers,
+		restart:       restart,
+		transport:     transport,
+		lastRaftIndex: metadata.RaftIndex,
+		confState:     metadata.ConfState,
+		waiter:        consensus.NewBlockWaiter(support.Height()),
+	}, nil
+}
+
+// Reader lets a deliver stream discover this chain's height and wait for a
+// future block without polling.
+func (c *chain) Reader() consensus.BlockReader {
+	return c.waiter
+}
+
+// openWAL opens the WAL at dir, creating it if necessary, and reports
+// whether it already existed, i.e. whether this is a node restarting with
+// Raft state to resume rather than one bootstrapping a brand new chain.
+func openWAL(dir string, storage *raft.MemoryStorage) (w *wal.WAL, restart bool, err error) {
+	if !wal.Exist(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, false, err
+		}
+		w, err = wal.Create(dir, nil)
+		return w, false, err
+	}
+
+	w, err = wal.Open(dir, walpb.Snapshot{})
+	if err != nil {
+		return nil, true, err
+	}
+	_, st, ents, err := w.ReadAll()
+	if err != nil {
+		return nil, true, err
+	}
+	storage.SetHardState(st)
+	storage.Append(ents)
+	return w, true, nil
+}
+
+// Start launches the Raft node and its driving goroutine. A restarting node
+// resumes solely from what its WAL and storage already hold; a bootstrapping
+// node is started with the peer list HandleChain resolved from either the
+// channel's current ConsensusType.Metadata or a prior block's ConfState.
+func (c *chain) Start() {
+	raftCfg := &raft.Config{
+		ID:              c.id,
+		ElectionTick:    10,
+		HeartbeatTick:   1,
+		Storage:         c.storage,
+		MaxSizePerMsg:   1024 * 1024,
+		MaxInflightMsgs: 256,
+	}
+	if c.restart {
+		// Entries up to the Raft index persisted in the last block were
+		// already applied; raft does not hand them back a second time.
+		raftCfg.Applied = c.lastRaftIndex
+		c.node = raft.RestartNode(raftCfg)
+	} else {
+		c.node = raft.StartNode(raftCfg, c.peers)
+	}
+	go c.main()
+}
+
+// Halt signals the driving goroutine to stop and waits for it to exit.
+func (c *chain) Halt() {
+	select {
+	case <-c.exitC:
+		// already halted
+	default:
+		close(c.haltC)
+		<-c.exitC
+	}
+}
+
+// Errored closes once the chain has stopped serving Raft.
+func (c *chain) Errored() <-chan struct{} {
+	return c.exitC
+}
+
+// proposal carries a single Order or Configure submission through Raft,
+// tagged with which one it was and the configSeq the caller validated it
+// against, mirroring solo's message struct so both consenters share the
+// same re-validation path once the entry commits. isCut carries no envelope;
+// it is the leader's replicated decision that a pending batch's timeout has
+// elapsed, analogous to kafka's TimeToCut message, so every replica cuts at
+// exactly the same point in its BlockCutter state instead of each following
+// its own local timer.
+type proposal struct {
+	envelope  *cb.Envelope
+	configSeq uint64
+	isConfig  bool
+	isCut     bool
+}
+
+// Order proposes env to the Raft group. It returns once the proposal has
+// been handed to Raft, not once it has been committed; the caller observes
+// commitment by watching the ledger height advance.
+func (c *chain) Order(env *cb.Envelope, configSeq uint64) error {
+	select {
+	case c.propC <- &proposal{envelope: env, configSeq: configSeq}:
+		return nil
+	case <-c.exitC:
+		return fmt.Errorf("chain is stopped")
+	}
+}
+
+// Configure proposes the already-computed config envelope the same way
+// Order does, so that on commit every replica cuts a config block instead of
+// folding it into the current batch.
+func (c *chain) Configure(configUpdate *cb.Envelope, config *cb.Envelope, configSeq uint64) error {
+	select {
+	case c.propC <- &proposal{envelope: config, configSeq: configSeq, isConfig: true}:
+		return nil
+	case <-c.exitC:
+		return fmt.Errorf("chain is stopped")
+	}
+}
+
+// main drains node.Ready(), persists it to the WAL, and applies committed
+// entries on every replica - leader and followers alike. It is the Raft
+// analogue of solo.chain.main.
+func (c *chain) main() {
+	var timer <-chan time.Time
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	defer close(c.exitC)
+
+	for {
+		select {
+		case p := <-c.propC:
+			data := marshalProposal(p)
+			if err := c.node.Propose(context.Background(), data); err != nil {
+				logger.Warningf("Failed to propose envelope to Raft: %s", err)
+			}
+		case <-ticker.C:
+			c.node.Tick()
+		case rd := <-c.node.Ready():
+			c.wal.Save(rd.HardState, rd.Entries)
+			if !raft.IsEmptySnap(rd.Snapshot) {
+				c.storage.ApplySnapshot(rd.Snapshot)
+			}
+			c.storage.Append(rd.Entries)
+
+			for _, entry := range rd.CommittedEntries {
+				c.lastRaftIndex = entry.Index
+				switch entry.Type {
+				case raftpb.EntryNormal:
+					if len(entry.Data) == 0 {
+						continue
+					}
+					p, err := unmarshalProposal(entry.Data)
+					if err != nil {
+						logger.Errorf("Failed to unmarshal committed Raft entry: %s", err)
+						continue
+					}
+					c.applyProposal(p, &timer)
+				case raftpb.EntryConfChange:
+					var cc raftpb.ConfChange
+					if err := cc.Unmarshal(entry.Data); err != nil {
+						logger.Errorf("Failed to unmarshal ConfChange: %s", err)
+						continue
+					}
+					if cs := c.node.ApplyConfChange(cc); cs != nil {
+						c.confState = *cs
+					}
+				}
+			}
+
+			if c.lastRaftIndex > 0 && c.lastRaftIndex%snapshotInterval == 0 {
+				height := make([]byte, 8)
+				binary.BigEndian.PutUint64(height, c.support.Height())
+				c.storage.CreateSnapshot(c.lastRaftIndex, nil, height)
+			}
+
+			for _, msg := range rd.Messages {
+				c.send(msg)
+			}
+			c.node.Advance()
+		case <-timer:
+			timer = nil
+			// Only the leader proposes the cut marker; every replica,
+			// including the leader itself, applies it once committed. A
+			// follower's own timer firing here does nothing: its pending
+			// batch is cut only when the leader's cut marker commits.
+			if c.isLeader() {
+				data := marshalProposal(&proposal{isCut: true})
+				if err := c.node.Propose(context.Background(), data); err != nil {
+					logger.Warningf("Failed to propose cut marker to Raft: %s", err)
+				}
+			}
+		case <-c.haltC:
+			c.node.Stop()
+			c.wal.Close()
+			return
+		}
+	}
+}
+
+// applyProposal feeds a committed proposal into the block cutter on every
+// replica. Because BlockCutter is deterministic given the same sequence of
+// committed entries, every replica reaches the same decision from the same
+// input and so cuts and writes the same block; apart from the membership
+// changes reconcileConsenters proposes, nothing here is leader-only.
+// If the proposal's configSeq is behind the channel's current configuration,
+// it is re-validated through ProcessNormalMsg/ProcessConfigMsg before being
+// cut, the same re-validation path solo.chain.main uses, so a config update
+// racing between validation and Raft commitment cannot corrupt the ledger.
+func (c *chain) applyProposal(p *proposal, timer *<-chan time.Time) {
+	switch {
+	case p.isCut:
+		batch := c.support.BlockCutter().Cut()
+		if len(batch) == 0 {
+			logger.Warningf("Got a cut marker but no pending requests, this might indicate a bug")
+			return
+		}
+		c.writeBatch(batch)
+		*timer = nil
+
+	case p.isConfig:
+		if batch := c.support.BlockCutter().Cut(); batch != nil {
+			c.writeBatch(batch)
+		}
+
+		if p.configSeq < c.support.Sequence() {
+			config, _, err := c.support.ProcessConfigMsg(p.envelope)
+			if err != nil {
+				logger.Warningf("Discarding bad config message: %s", err)
+				return
+			}
+			p.envelope = config
+		}
+
+		c.writeConfigBlock(p.envelope)
+		*timer = nil
+		c.reconcileConsenters()
+
+	default:
+		if p.configSeq < c.support.Sequence() {
+			if _, err := c.support.ProcessNormalMsg(p.envelope); err != nil {
+				logger.Warningf("Discarding bad normal message: %s", err)
+				return
+			}
+		}
+
+		batches, ok := c.support.BlockCutter().Ordered(p.envelope)
+		if ok && len(batches) == 0 && *timer == nil {
+			*timer = time.After(c.support.SharedConfig().BatchTimeout())
+			return
+		}
+		for _, batch := range batches {
+			c.writeBatch(batch)
+		}
+		if len(batches) > 0 {
+			*timer = nil
+		}
+	}
+}
+
+// reconcileConsenters decodes the channel's current ConsensusType.Metadata,
+// now that a config block has just been written, and proposes whatever
+// ConfChange entries are needed to bring this Raft group's membership in
+// line with it. Every replica calls applyProposal, but only the leader acts
+// here, the same pattern used for the cut marker: raft.Status().Progress is
+// only populated on the node that is currently leader, so a follower has no
+// way to diff "wanted" against "current" and would otherwise re-propose
+// every configured consenter as an add on every single config commit.
+func (c *chain) reconcileConsenters() {
+	if !c.isLeader() {
+		return
+	}
+
+	m, err := unmarshalConfigMetadata(c.support.SharedConfig().ConsensusMetadata())
+	if err != nil {
+		logger.Errorf("Failed to parse consensus metadata after config update: %s", err)
+		return
+	}
+
+	wanted := map[uint64]bool{}
+	for _, cst := range m.Consenters {
+		wanted[cst.ID] = true
+	}
+
+	current := map[uint64]bool{}
+	for id := range c.node.Status().Progress {
+		current[id] = true
+	}
+
+	for id := range wanted {
+		if !current[id] {
+			c.proposeConfChange(raftpb.ConfChangeAddNode, id)
+		}
+	}
+	for id := range current {
+		if !wanted[id] {
+			c.proposeConfChange(raftpb.ConfChangeRemoveNode, id)
+		}
+	}
+}
+
+// proposeConfChange is only ever called from applyProposal, itself only
+// called from main's own goroutine, so it proposes directly rather than
+// routing back through a channel main would have to also be the one
+// draining.
+func (c *chain) proposeConfChange(changeType raftpb.ConfChangeType, id uint64) {
+	cc := raftpb.ConfChange{Type: changeType, NodeID: id}
+	if err := c.node.ProposeConfChange(context.Background(), cc); err != nil {
+		logger.Warningf("Failed to propose config change to Raft: %s", err)
+	}
+}
+
+func (c *chain) writeBatch(batch []*cb.Envelope) {
+	block := c.support.CreateNextBlock(batch)
+	c.attachRaftMetadata(block)
+	c.support.WriteBlock(block, nil)
+	c.waiter.Notify(c.support.Height())
+}
+
+func (c *chain) writeConfigBlock(env *cb.Envelope) {
+	block := c.support.CreateNextBlock([]*cb.Envelope{env})
+	c.attachRaftMetadata(block)
+	c.support.WriteConfigBlock(block, nil)
+	c.waiter.Notify(c.support.Height())
+}
+
+// attachRaftMetadata stamps the Raft index that produced this block into
+// Metadata[BlockMetadataIndex_ORDERER], so a restarting node resumes from
+// exactly this point rather than replaying from the beginning of the WAL.
+func (c *chain) attachRaftMetadata(block *cb.Block) {
+	m := &RaftMetadata{RaftIndex: c.lastRaftIndex, ConfState: c.confState}
+	for len(block.Metadata.Metadata) <= int(cb.BlockMetadataIndex_ORDERER) {
+		block.Metadata.Metadata = append(block.Metadata.Metadata, nil)
+	}
+	block.Metadata.Metadata[cb.BlockMetadataIndex_ORDERER] = marshalRaftMetadata(m)
+}
+
+func (c *chain) isLeader() bool {
+	return c.node.Status().Lead == c.id
+}
+
+// send dispatches a Raft message to its destination peer through c.transport.
+func (c *chain) send(msg raftpb.Message) {
+	c.transport.Send(msg.To, msg)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package etcdraft implements a crash-fault-tolerant consenter which
+// replicates ordering decisions across a cluster of orderer nodes using the
+// Raft protocol (go.etcd.io/etcd/raft). Unlike solo, which trusts a single
+// process to order and cut blocks, etcdraft requires a quorum of consenters
+// to agree on the order of envelopes before they are cut into a block.
+package etcdraft
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric/orderer/consensus"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("orderer/consensus/etcdraft")
+
+// consenter implements consensus.Consenter for the Raft-based ordering service.
+type consenter struct {
+	// WALBaseDir is the directory under which each chain's WAL is stored,
+	// one subdirectory per channel.
+	WALBaseDir string
+
+	// NodeID is this orderer's Raft node ID. It must be non-zero (etcd/raft
+	// treats ID 0 as None, meaning "no node") and unique among the consenters
+	// of every channel this orderer participates in.
+	NodeID uint64
+
+	// Transport delivers this consenter's Raft messages to the rest of a
+	// channel's cluster. It is shared by every chain HandleChain returns.
+	Transport Transport
+}
+
+// New creates a new Raft consenter. nodeID is this orderer's Raft node ID,
+// assigned out of band (e.g. from local configuration) and shared across
+// every channel this orderer participates in. walBaseDir is the root
+// directory under which per-channel WAL and snapshot directories are
+// created. transport delivers this consenter's Raft messages to its peers
+// (e.g. over a gRPC "cluster" service); a nil transport falls back to one
+// that logs instead of sending, so a consenter can still be constructed
+// before that service exists.
+func New(nodeID uint64, walBaseDir string, transport Transport) consensus.Consenter {
+	if nodeID == 0 {
+		logger.Panicf("Raft node ID must be non-zero")
+	}
+	if transport == nil {
+		transport = noopTransport{}
+	}
+	return &consenter{NodeID: nodeID, WALBaseDir: walBaseDir, Transport: transport}
+}
+
+// HandleChain returns a Raft-backed Chain for the given channel. metadata,
+// when non-nil, is the last persisted RaftMetadata for this chain (resuming
+// an existing chain after restart) serialized the same way it is written to
+// a block's ORDERER metadata field by WriteBlock/WriteConfigBlock.
+func (c *consenter) HandleChain(support consensus.ConsenterSupport, metadata *cb.Metadata) (consensus.Chain, error) {
+	var raftMetadata []byte
+	if metadata != nil {
+		raftMetadata = metadata.Value
+	}
+
+	m, err := unmarshalRaftMetadata(raftMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := raftPeersFromConfState(m.ConfState)
+	if len(peers) == 0 {
+		// No ConfState to resume from: this chain is being bootstrapped for
+		// the first time, so seed its initial membership from the channel's
+		// currently configured consenter set instead.
+		configMetadata, err := unmarshalConfigMetadata(support.SharedConfig().ConsensusMetadata())
+		if err != nil {
+			return nil, err
+		}
+		peers = raftPeersFromConfig(configMetadata)
+	}
+
+	return newChain(support, c.NodeID, filepath.Join(c.WALBaseDir, support.ChainID()), m, peers, c.Transport)
+}
+
+// ValidateMetadata checks that metadata deserializes into a ConfigMetadata
+// naming at least one consenter, so a channel cannot be created with a Raft
+// cluster of zero members.
+func (c *consenter) ValidateMetadata(metadata []byte) error {
+	m, err := unmarshalConfigMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	if len(m.Consenters) == 0 {
+		return fmt.Errorf("etcdraft ConsensusType.Metadata must name at least one consenter")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package etcdraft
+
+import (
+	"encoding/json"
+
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/utils"
+)
+
+// wireProposal is the on-the-wire form of a proposal, carried as the Data of
+// a raftpb.Entry so that every replica which applies the entry - not just the
+// one that proposed it - knows whether it was an Order or a Configure call
+// and which configSeq it was validated against. A cut marker carries no
+// Envelope, so IsCut is checked before Envelope is unmarshaled.
+type wireProposal struct {
+	Envelope  []byte
+	ConfigSeq uint64
+	IsConfig  bool
+	IsCut     bool
+}
+
+func marshalProposal(p *proposal) []byte {
+	wp := &wireProposal{ConfigSeq: p.configSeq, IsConfig: p.isConfig, IsCut: p.isCut}
+	if p.envelope != nil {
+		wp.Envelope = utils.MarshalOrPanic(p.envelope)
+	}
+
+	b, err := json.Marshal(wp)
+	if err != nil {
+		logger.Panicf("Unexpected failure marshaling proposal: %s", err)
+	}
+	return b
+}
+
+func unmarshalProposal(data []byte) (*proposal, error) {
+	wp := &wireProposal{}
+	if err := json.Unmarshal(data, wp); err != nil {
+		return nil, err
+	}
+
+	if wp.IsCut {
+		return &proposal{isCut: true}, nil
+	}
+
+	env := &cb.Envelope{}
+	if err := utils.Unmarshal(wp.Envelope, env); err != nil {
+		return nil, err
+	}
+
+	return &proposal{envelope: env, configSeq: wp.ConfigSeq, isConfig: wp.IsConfig}, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package etcdraft
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+)
+
+// RaftMetadata is persisted into every block's ORDERER metadata field so that
+// a restarting node can resume Raft from the last committed index without
+// replaying the entire WAL from the beginning, and so that newly joining
+// nodes can learn the current configuration.
+type RaftMetadata struct {
+	// RaftIndex is the Raft log index of the entry that produced this block.
+	RaftIndex uint64
+	// ConfState reflects the set of consenters as of this block.
+	ConfState raftpb.ConfState
+}
+
+// marshalRaftMetadata serializes m for storage in a block's Metadata field.
+func marshalRaftMetadata(m *RaftMetadata) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, m.RaftIndex)
+
+	confState, err := proto.Marshal(&m.ConfState)
+	if err != nil {
+		// ConfState is a plain struct of uint64 slices, marshaling cannot fail
+		logger.Panicf("Unexpected failure marshaling ConfState: %s", err)
+	}
+
+	return append(buf, confState...)
+}
+
+// unmarshalRaftMetadata parses the bytes previously produced by marshalRaftMetadata.
+// A nil or empty input is treated as "no prior state" and returns a zero value,
+// which is the expected case when a chain is bootstrapped for the first time.
+func unmarshalRaftMetadata(b []byte) (*RaftMetadata, error) {
+	m := &RaftMetadata{}
+	if len(b) == 0 {
+		return m, nil
+	}
+	if len(b) < 8 {
+		return nil, fmt.Errorf("raft metadata too short: %d bytes", len(b))
+	}
+
+	m.RaftIndex = binary.BigEndian.Uint64(b[:8])
+	if err := proto.Unmarshal(b[8:], &m.ConfState); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling ConfState: %s", err)
+	}
+
+	return m, nil
+}
+
+// raftMetadataFromBlock extracts the persisted RaftMetadata from a block written
+// by this consenter, or a zero value if the block predates this consenter.
+func raftMetadataFromBlock(block *common.Block) (*RaftMetadata, error) {
+	if block == nil || block.Metadata == nil || len(block.Metadata.Metadata) <= int(common.BlockMetadataIndex_ORDERER) {
+		return &RaftMetadata{}, nil
+	}
+	return unmarshalRaftMetadata(block.Metadata.Metadata[common.BlockMetadataIndex_ORDERER])
+}
+
+// RaftConsenter identifies one member of a channel's Raft cluster.
+type RaftConsenter struct {
+	ID   uint64
+	Host string
+	Port uint32
+}
+
+// ConfigMetadata is the ConsensusType.Metadata format this consenter expects
+// a channel's config to carry: the set of orderers participating in that
+// channel's Raft cluster. HandleChain decodes it to learn the initial peer
+// list when bootstrapping a chain with no prior ConfState to resume from,
+// and a chain re-decodes it out of SharedConfig() after every config update
+// to reconcile cluster membership via reconcileConsenters.
+type ConfigMetadata struct {
+	Consenters []RaftConsenter
+}
+
+// unmarshalConfigMetadata parses a channel's ConsensusType.Metadata, encoded
+// by whatever tooling produced that channel's config. A nil or empty input
+// is treated as "no configured consenters" and returns a zero value.
+func unmarshalConfigMetadata(b []byte) (*ConfigMetadata, error) {
+	m := &ConfigMetadata{}
+	if len(b) == 0 {
+		return m, nil
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling ConfigMetadata: %s", err)
+	}
+	return m, nil
+}
+
+// raftPeersFromConfig builds the raft.Peer list StartNode needs to bootstrap
+// a brand new cluster from a set of configured consenters.
+func raftPeersFromConfig(m *ConfigMetadata) []raft.Peer {
+	peers := make([]raft.Peer, len(m.Consenters))
+	for i, c := range m.Consenters {
+		peers[i] = raft.Peer{ID: c.ID}
+	}
+	return peers
+}
+
+// raftPeersFromConfState rebuilds the raft.Peer list from a ConfState
+// previously persisted into a block, so a node resuming a channel it already
+// has blocks for restores the cluster it was last part of rather than
+// re-bootstrapping from the channel's current config.
+func raftPeersFromConfState(cs raftpb.ConfState) []raft.Peer {
+	peers := make([]raft.Peer, len(cs.Nodes))
+	for i, id := range cs.Nodes {
+		peers[i] = raft.Peer{ID: id}
+	}
+	return peers
+}
@@ -0,0 +1,29 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package etcdraft
+
+import "go.etcd.io/etcd/raft/raftpb"
+
+// Transport delivers a Raft message to the consenter identified by to. A
+// chain never addresses peers itself; it only knows their Raft node IDs, the
+// same IDs ConfigMetadata.Consenters/raftpb.ConfState carry, and leaves
+// resolving an ID to an actual connection up to the Transport implementation
+// (e.g. one backed by a gRPC "cluster" service shared by every chain this
+// orderer hosts).
+type Transport interface {
+	Send(to uint64, msg raftpb.Message)
+}
+
+// noopTransport is the Transport a consenter falls back to when none is
+// supplied. It makes the gap visible in the log rather than silently
+// dropping messages, since a real implementation (e.g. over a gRPC "cluster"
+// service) is expected to be injected by whatever constructs the consenter.
+type noopTransport struct{}
+
+func (noopTransport) Send(to uint64, msg raftpb.Message) {
+	logger.Debugf("No Transport configured: dropping Raft message type %v bound for node %d", msg.Type, to)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/utils"
+)
+
+// chain implements consensus.Chain on top of a single Kafka topic-partition
+// shared by every orderer replicating this channel. It plays the role of
+// solo.chain.main, but Order/Configure produce onto the partition instead of
+// writing to an in-process channel, and the consume loop - not a private
+// timer - is what every replica uses to decide when a block is cut.
+type chain struct {
+	consenter *consenter
+	support   consensus.ConsenterSupport
+	partition int32
+	topic     string
+
+	producer sarama.SyncProducer
+	consumer sarama.PartitionConsumer
+
+	lastOffsetPersisted int64
+	lastCutBlockNumber  uint64
+
+	haltC chan struct{}
+	exitC chan struct{}
+
+	waiter *consensus.BlockWaiter
+}
+
+func newChain(co *consenter, support consensus.ConsenterSupport, metadata *KafkaMetadata) (*chain, error) {
+	return &chain{
+		consenter:           co,
+		support:             support,
+		topic:               support.ChainID(),
+		partition:           0,
+		lastOffsetPersisted: metadata.LastOffsetPersisted,
+		lastCutBlockNumber:  metadata.LastCutBlockNumber,
+		haltC:               make(chan struct{}),
+		exitC:               make(chan struct{}),
+		waiter:              consensus.NewBlockWaiter(support.Height()),
+	}, nil
+}
+
+// Reader lets a deliver stream discover this chain's height and wait for a
+// future block without polling.
+func (c *chain) Reader() consensus.BlockReader {
+	return c.waiter
+}
+
+// Start produces a CONNECT message to prove the topic-partition is reachable,
+// opens a PartitionConsumer at the offset following the last one this chain
+// persisted, and launches the consume loop.
+func (c *chain) Start() {
+	producer, err := sarama.NewSyncProducer(c.consenter.Brokers, c.consenter.Config)
+	if err != nil {
+		logger.Panicf("Failed to create Kafka producer for channel %s: %s", c.topic, err)
+	}
+	c.producer = producer
+
+	if _, _, err := producer.SendMessage(c.wrapMessage(marshalKafkaMessage(newConnectMessage()))); err != nil {
+		logger.Panicf("Failed to post CONNECT message to channel %s: %s", c.topic, err)
+	}
+
+	startOffset := sarama.OffsetOldest
+	if c.lastOffsetPersisted >= 0 {
+		startOffset = c.lastOffsetPersisted + 1
+	}
+
+	consumer, err := sarama.NewConsumer(c.consenter.Brokers, c.consenter.Config)
+	if err != nil {
+		logger.Panicf("Failed to create Kafka consumer for channel %s: %s", c.topic, err)
+	}
+	partitionConsumer, err := consumer.ConsumePartition(c.topic, c.partition, startOffset)
+	if err != nil {
+		logger.Panicf("Failed to consume partition for channel %s: %s", c.topic, err)
+	}
+	c.consumer = partitionConsumer
+
+	go c.loop()
+}
+
+// Halt stops the consume loop and releases the producer/consumer.
+func (c *chain) Halt() {
+	select {
+	case <-c.exitC:
+	default:
+		close(c.haltC)
+		<-c.exitC
+	}
+}
+
+// Errored closes once the chain has stopped consuming.
+func (c *chain) Errored() <-chan struct{} {
+	return c.exitC
+}
+
+// Order produces env as a regular message on the channel's partition.
+func (c *chain) Order(env *cb.Envelope, configSeq uint64) error {
+	return c.produceRegular(env, configSeq, false)
+}
+
+// Configure produces the already-computed config envelope the same way
+// Order does, tagged as a config message so that on consume every replica
+// cuts a config block instead of folding it into the pending batch.
+func (c *chain) Configure(configUpdate *cb.Envelope, config *cb.Envelope, configSeq uint64) error {
+	return c.produceRegular(config, configSeq, true)
+}
+
+func (c *chain) produceRegular(env *cb.Envelope, configSeq uint64, isConfig bool) error {
+	payload := utils.MarshalOrPanic(env)
+	_, _, err := c.producer.SendMessage(c.wrapMessage(marshalKafkaMessage(newRegularMessage(payload, configSeq, isConfig))))
+	if err != nil {
+		return fmt.Errorf("failed to post message to channel %s: %s", c.topic, err)
+	}
+	return nil
+}
+
+func (c *chain) wrapMessage(value []byte) *sarama.ProducerMessage {
+	return &sarama.ProducerMessage{Topic: c.topic, Partition: c.partition, Value: sarama.ByteEncoder(value)}
+}
+
+// loop consumes the channel's partition. It is the sole place blocks are cut:
+// every replica sees the same sequence of regular and TimeToCut messages, so
+// cutting a timed-out batch only in response to a valid, in-order TimeToCut
+// (and cutting filled batches and config messages as they are consumed)
+// keeps every replica's ledger identical without requiring a leader.
+func (c *chain) loop() {
+	defer close(c.exitC)
+	defer c.producer.Close()
+	defer c.consumer.Close()
+
+	var timer <-chan time.Time
+	expectedBlockNumber := c.lastCutBlockNumber + 1
+
+	for {
+		select {
+		case in := <-c.consumer.Messages():
+			msg, err := unmarshalKafkaMessage(in.Value)
+			if err != nil {
+				logger.Warningf("Discarding unparseable Kafka message at offset %d: %s", in.Offset, err)
+				continue
+			}
+
+			switch msg.Type {
+			case messageTypeConnect:
+				logger.Debugf("Discarding CONNECT message at offset %d", in.Offset)
+
+			case messageTypeRegular:
+				env := &cb.Envelope{}
+				if err := utils.Unmarshal(msg.Regular, env); err != nil {
+					logger.Warningf("Discarding unparseable envelope at offset %d: %s", in.Offset, err)
+					continue
+				}
+
+				if msg.IsConfig {
+					if msg.ConfigSeq < c.support.Sequence() {
+						config, _, err := c.support.ProcessConfigMsg(env)
+						if err != nil {
+							logger.Warningf("Discarding bad config message at offset %d: %s", in.Offset, err)
+							continue
+						}
+						env = config
+					}
+					if batch := c.support.BlockCutter().Cut(); batch != nil {
+						c.writeBlock(batch, in.Offset, false)
+						expectedBlockNumber++
+					}
+					c.writeBlock([]*cb.Envelope{env}, in.Offset, true)
+					expectedBlockNumber++
+					timer = nil
+					continue
+				}
+
+				if msg.ConfigSeq < c.support.Sequence() {
+					if _, err := c.support.ProcessNormalMsg(env); err != nil {
+						logger.Warningf("Discarding bad normal message at offset %d: %s", in.Offset, err)
+						continue
+					}
+				}
+
+				batches, pending := c.support.BlockCutter().Ordered(env)
+				if pending && len(batches) == 0 && timer == nil {
+					timer = time.After(c.support.SharedConfig().BatchTimeout())
+					continue
+				}
+				// batches are already cut and removed from the cutter's
+				// pending state, so they must be written now: waiting for
+				// the next TimeToCut would have Cut() return whatever is
+				// newly pending instead, silently dropping them.
+				for _, batch := range batches {
+					c.writeBlock(batch, in.Offset, false)
+					expectedBlockNumber++
+				}
+				if len(batches) > 0 {
+					timer = nil
+				}
+
+			case messageTypeTimeToCut:
+				if msg.TimeToCutBlockNumber != expectedBlockNumber {
+					logger.Debugf("Discarding stale/duplicate TimeToCut(%d), expected %d", msg.TimeToCutBlockNumber, expectedBlockNumber)
+					continue
+				}
+				batch := c.support.BlockCutter().Cut()
+				if len(batch) == 0 {
+					logger.Warningf("Got valid TimeToCut(%d) but no pending messages, this might indicate a bug", msg.TimeToCutBlockNumber)
+					continue
+				}
+				c.writeBlock(batch, in.Offset, false)
+				expectedBlockNumber++
+				timer = nil
+			}
+
+		case <-timer:
+			timer = nil
+			if _, _, err := c.producer.SendMessage(c.wrapMessage(marshalKafkaMessage(newTimeToCutMessage(expectedBlockNumber)))); err != nil {
+				logger.Warningf("Failed to post TimeToCut(%d) to channel %s: %s", expectedBlockNumber, c.topic, err)
+			}
+
+		case <-c.haltC:
+			logger.Debugf("Exiting consume loop for channel %s", c.topic)
+			return
+		}
+	}
+}
+
+func (c *chain) writeBlock(batch []*cb.Envelope, offset int64, isConfig bool) {
+	block := c.support.CreateNextBlock(batch)
+	c.lastOffsetPersisted = offset
+	c.lastCutBlockNumber++
+	c.attachKafkaMetadata(block)
+
+	if isConfig {
+		c.support.WriteConfigBlock(block, nil)
+	} else {
+		c.support.WriteBlock(block, nil)
+	}
+	c.waiter.Notify(c.support.Height())
+}
+
+// attachKafkaMetadata stamps the Kafka offset and block number that produced
+// this block into Metadata[BlockMetadataIndex_ORDERER], so a restarting
+// replica resumes consuming from the next offset and every replica can spot
+// stale TimeToCut messages for blocks it already cut.
+func (c *chain) attachKafkaMetadata(block *cb.Block) {
+	m := &KafkaMetadata{LastOffsetPersisted: c.lastOffsetPersisted, LastCutBlockNumber: c.lastCutBlockNumber}
+	for len(block.Metadata.Metadata) <= int(cb.BlockMetadataIndex_ORDERER) {
+		block.Metadata.Metadata = append(block.Metadata.Metadata, nil)
+	}
+	block.Metadata.Metadata[cb.BlockMetadataIndex_ORDERER] = marshalKafkaMetadata(m)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package kafka implements a consenter that replicates ordering decisions
+// through a Kafka partition shared by every orderer of a channel, rather
+// than solo's in-process channel. A channel's envelopes, and the control
+// messages used to coordinate batch cutting across replicas, are both
+// ordinary messages on that partition; the partition's own total order is
+// what every replica agrees on.
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("orderer/consensus/kafka")
+
+// consenter implements consensus.Consenter for the Kafka-backed ordering
+// service. Brokers is shared by every chain handled by this consenter.
+type consenter struct {
+	Brokers []string
+	Config  *sarama.Config
+}
+
+// New creates a new Kafka consenter which produces to and consumes from the
+// given set of brokers.
+func New(brokers []string, config *sarama.Config) consensus.Consenter {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+
+	return &consenter{Brokers: brokers, Config: config}
+}
+
+// HandleChain returns a Kafka-backed Chain for the given channel. metadata,
+// when non-nil, carries the last persisted KafkaMetadata for this chain
+// (encoded the same way it is written to a block's ORDERER metadata field),
+// so the chain resumes consuming from the right offset after a restart.
+func (co *consenter) HandleChain(support consensus.ConsenterSupport, metadata *cb.Metadata) (consensus.Chain, error) {
+	var raw []byte
+	if metadata != nil {
+		raw = metadata.Value
+	}
+
+	m, err := unmarshalKafkaMetadata(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return newChain(co, support, m)
+}
+
+// ValidateMetadata always succeeds: kafka's brokers are supplied at process
+// startup, not through a channel's ConsensusType.Metadata, so there is
+// nothing in it for a channel's config to get wrong.
+func (co *consenter) ValidateMetadata(metadata []byte) error {
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kafka
+
+import "encoding/json"
+
+// messageType discriminates the payload carried by a message produced onto
+// a channel's partition. All orderers sharing that partition must agree on
+// this encoding, so it is kept deliberately small and stable.
+type messageType byte
+
+const (
+	// messageTypeConnect is produced once by Start to probe that the
+	// channel's topic-partition is reachable before the consume loop begins.
+	// It carries no payload and every replica simply discards it on consume.
+	messageTypeConnect messageType = iota
+	// messageTypeRegular wraps a marshaled *cb.Envelope submitted via
+	// Order/Configure.
+	messageTypeRegular
+	// messageTypeTimeToCut tells every replica that the batch timer for
+	// TimeToCutBlockNumber expired on whichever replica produced it, and that block
+	// should now be cut deterministically.
+	messageTypeTimeToCut
+)
+
+// kafkaMessage is the single envelope produced onto a channel's partition;
+// only the fields relevant to Type are meaningful.
+type kafkaMessage struct {
+	Type                 messageType
+	Regular              []byte // marshaled *cb.Envelope, set when Type == messageTypeRegular
+	ConfigSeq            uint64 // the configSeq Order/Configure validated Regular against
+	IsConfig             bool   // true if Regular was submitted via Configure rather than Order
+	TimeToCutBlockNumber uint64 // set when Type == messageTypeTimeToCut
+}
+
+func marshalKafkaMessage(m *kafkaMessage) []byte {
+	b, err := json.Marshal(m)
+	if err != nil {
+		logger.Panicf("Unexpected failure marshaling kafka message: %s", err)
+	}
+	return b
+}
+
+func unmarshalKafkaMessage(b []byte) (*kafkaMessage, error) {
+	m := &kafkaMessage{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func newConnectMessage() *kafkaMessage {
+	return &kafkaMessage{Type: messageTypeConnect}
+}
+
+func newRegularMessage(payload []byte, configSeq uint64, isConfig bool) *kafkaMessage {
+	return &kafkaMessage{Type: messageTypeRegular, Regular: payload, ConfigSeq: configSeq, IsConfig: isConfig}
+}
+
+func newTimeToCutMessage(blockNumber uint64) *kafkaMessage {
+	return &kafkaMessage{Type: messageTypeTimeToCut, TimeToCutBlockNumber: blockNumber}
+}
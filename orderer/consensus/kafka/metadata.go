@@ -0,0 +1,59 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+// KafkaMetadata is persisted into every block's ORDERER metadata field. It
+// lets a restarting orderer resume consuming its channel's partition from
+// exactly where it left off, and lets every replica agree on which block
+// number is next without re-deriving it from the Kafka offset alone (a
+// replica may have consumed, but not yet cut, messages past the last block).
+type KafkaMetadata struct {
+	// LastOffsetPersisted is the offset of the last Kafka message (TimeToCut
+	// included) reflected in this block.
+	LastOffsetPersisted int64
+	// LastCutBlockNumber is this block's own number, recorded so a replica
+	// resuming mid-stream can tell which TimeToCut messages are stale.
+	LastCutBlockNumber uint64
+}
+
+func marshalKafkaMetadata(m *KafkaMetadata) []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], uint64(m.LastOffsetPersisted))
+	binary.BigEndian.PutUint64(buf[8:], m.LastCutBlockNumber)
+	return buf
+}
+
+func unmarshalKafkaMetadata(b []byte) (*KafkaMetadata, error) {
+	if len(b) == 0 {
+		return &KafkaMetadata{LastOffsetPersisted: -1}, nil
+	}
+	if len(b) != 16 {
+		return nil, fmt.Errorf("kafka metadata has unexpected length %d", len(b))
+	}
+	return &KafkaMetadata{
+		LastOffsetPersisted: int64(binary.BigEndian.Uint64(b[:8])),
+		LastCutBlockNumber:  binary.BigEndian.Uint64(b[8:]),
+	}, nil
+}
+
+// kafkaMetadataFromBlock extracts the persisted KafkaMetadata from the last
+// block of the channel's ledger, or a value with LastOffsetPersisted -1 (so
+// consumption starts at the oldest offset) if the channel has no blocks
+// written by this consenter yet.
+func kafkaMetadataFromBlock(block *common.Block) (*KafkaMetadata, error) {
+	if block == nil || block.Metadata == nil || len(block.Metadata.Metadata) <= int(common.BlockMetadataIndex_ORDERER) {
+		return &KafkaMetadata{LastOffsetPersisted: -1}, nil
+	}
+	return unmarshalKafkaMetadata(block.Metadata.Metadata[common.BlockMetadataIndex_ORDERER])
+}
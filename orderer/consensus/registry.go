@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package consensus
+
+import "fmt"
+
+// registry holds every Consenter plugin known to this process, keyed by the
+// name carried in a channel's ConsensusType.Type (e.g. "solo", "kafka",
+// "etcdraft"). Plugins register themselves from their package's init or from
+// orderer startup wiring; multichannel.Registrar looks a chain's consenter
+// up here by name when bootstrapping a channel or handling an
+// ORDERER_TRANSACTION for a newly created one.
+var registry = map[string]Consenter{}
+
+// Register makes a Consenter available under name for subsequent lookups by
+// Lookup. It panics if name is already registered, since two plugins
+// claiming the same ConsensusType.Type is a startup-time configuration
+// mistake, not a runtime condition to recover from.
+func Register(name string, c Consenter) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("consensus plugin already registered under name %q", name))
+	}
+	registry[name] = c
+}
+
+// Lookup returns the Consenter registered under name, or false if no plugin
+// has claimed that name. Callers processing a channel's ConsensusType should
+// treat a false return as a configuration error: the channel requested a
+// consensus implementation this orderer does not have.
+func Lookup(name string) (Consenter, bool) {
+	c, ok := registry[name]
+	return c, ok
+}
+
+// Validator implements msgprocessor.ConsensusTypeValidator against this
+// package's registry. It lives here, rather than in msgprocessor itself, so
+// that msgprocessor never needs to import this package: msgprocessor already
+// flows into ConsenterSupport in consensus.go, and the reverse import would be a
+// cycle. Whatever constructs a msgprocessor.SystemChannel for this orderer
+// passes a Validator{} to it.
+type Validator struct{}
+
+// ValidateConsensusType reports whether consensusType names a registered
+// Consenter and metadata deserializes into that plugin's expected format.
+func (Validator) ValidateConsensusType(consensusType string, metadata []byte) error {
+	c, ok := Lookup(consensusType)
+	if !ok {
+		return fmt.Errorf("unregistered consensus type %q", consensusType)
+	}
+	if err := c.ValidateMetadata(metadata); err != nil {
+		return fmt.Errorf("invalid metadata for consensus type %q: %s", consensusType, err)
+	}
+	return nil
+}
@@ -20,21 +20,34 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/hyperledger/fabric/orderer/common/msgprocessor"
 	"github.com/hyperledger/fabric/orderer/consensus"
 	cb "github.com/hyperledger/fabric/protos/common"
-	"github.com/hyperledger/fabric/protos/utils"
 	"github.com/op/go-logging"
 )
 
 var logger = logging.MustGetLogger("orderer/solo")
 
+func init() {
+	consensus.Register("solo", New())
+}
+
 type consenter struct{}
 
+// message carries a single Order or Configure submission through sendChan,
+// tagged with which one it was (normalMsg vs configMsg) and the configSeq
+// the caller validated it against, so main can detect and correct for a
+// config update racing in between validation and ordering.
+type message struct {
+	configSeq uint64
+	normalMsg *cb.Envelope
+	configMsg *cb.Envelope
+}
+
 type chain struct {
 	support  consensus.ConsenterSupport
-	sendChan chan *cb.Envelope
+	sendChan chan *message
 	exitChan chan struct{}
+	waiter   *consensus.BlockWaiter
 }
 
 // New creates a new consenter for the solo consensus scheme.
@@ -49,11 +62,18 @@ func (solo *consenter) HandleChain(support consensus.ConsenterSupport, metadata
 	return newChain(support), nil
 }
 
+// ValidateMetadata always succeeds: solo takes no ConsensusType.Metadata, so
+// there is nothing for a channel's config to get wrong.
+func (solo *consenter) ValidateMetadata(metadata []byte) error {
+	return nil
+}
+
 func newChain(support consensus.ConsenterSupport) *chain {
 	return &chain{
 		support:  support,
-		sendChan: make(chan *cb.Envelope),
+		sendChan: make(chan *message),
 		exitChan: make(chan struct{}),
+		waiter:   consensus.NewBlockWaiter(support.Height()),
 	}
 }
 
@@ -73,17 +93,21 @@ func (ch *chain) Halt() {
 // Order accepts normal messages for ordering
 func (ch *chain) Order(env *cb.Envelope, configSeq uint64) error {
 	select {
-	case ch.sendChan <- env:
+	case ch.sendChan <- &message{configSeq: configSeq, normalMsg: env}:
 		return nil
 	case <-ch.exitChan:
 		return fmt.Errorf("Exiting")
 	}
 }
 
-// Order accepts normal messages for ordering
+// Configure accepts a config update message for ordering
 func (ch *chain) Configure(configUpdate *cb.Envelope, config *cb.Envelope, configSeq uint64) error {
-	// TODO, handle this specially
-	return ch.Order(config, configSeq)
+	select {
+	case ch.sendChan <- &message{configSeq: configSeq, configMsg: config}:
+		return nil
+	case <-ch.exitChan:
+		return fmt.Errorf("Exiting")
+	}
 }
 
 // Errored only closes on exit
@@ -91,59 +115,73 @@ func (ch *chain) Errored() <-chan struct{} {
 	return ch.exitChan
 }
 
+// Reader lets a deliver stream discover this chain's height and wait for a
+// future block without polling.
+func (ch *chain) Reader() consensus.BlockReader {
+	return ch.waiter
+}
+
+// writeBlock appends block to the ledger and wakes anyone blocked in
+// Reader().WaitForBlock, so a subscribed deliver stream does not have to
+// poll for a block it is waiting on.
+func (ch *chain) writeBlock(block *cb.Block) {
+	ch.support.WriteBlock(block, nil)
+	ch.waiter.Notify(ch.support.Height())
+}
+
+// writeConfigBlock is writeBlock's counterpart for config blocks.
+func (ch *chain) writeConfigBlock(block *cb.Block) {
+	ch.support.WriteConfigBlock(block, nil)
+	ch.waiter.Notify(ch.support.Height())
+}
+
 func (ch *chain) main() {
 	var timer <-chan time.Time
 
 	for {
 		select {
 		case msg := <-ch.sendChan:
-			chdr, err := utils.ChannelHeader(msg)
-			if err != nil {
-				logger.Panicf("If a message has arrived to this point, it should already have had its header inspected once")
-			}
-
-			class, err := ch.support.ClassifyMsg(chdr)
-			if err != nil {
-				logger.Panicf("If a message has arrived to this point, it should already have been classified once: %s", err)
-			}
-			switch class {
-			case msgprocessor.ConfigUpdateMsg:
-				_, err := ch.support.ProcessNormalMsg(msg)
-				if err != nil {
-					logger.Warningf("Discarding bad config message: %s", err)
-					continue
+			if msg.configMsg == nil {
+				// NormalMsg
+				if msg.configSeq < ch.support.Sequence() {
+					_, err := ch.support.ProcessNormalMsg(msg.normalMsg)
+					if err != nil {
+						logger.Warningf("Discarding bad normal message: %s", err)
+						continue
+					}
 				}
 
-				batch := ch.support.BlockCutter().Cut()
-				if batch != nil {
-					block := ch.support.CreateNextBlock(batch)
-					ch.support.WriteBlock(block, nil)
-				}
-
-				block := ch.support.CreateNextBlock([]*cb.Envelope{msg})
-				ch.support.WriteConfigBlock(block, nil)
-				timer = nil
-			case msgprocessor.NormalMsg:
-				_, err := ch.support.ProcessNormalMsg(msg)
-				if err != nil {
-					logger.Warningf("Discarding bad normal message: %s", err)
-					continue
-				}
-
-				batches, ok := ch.support.BlockCutter().Ordered(msg)
+				batches, ok := ch.support.BlockCutter().Ordered(msg.normalMsg)
 				if ok && len(batches) == 0 && timer == nil {
 					timer = time.After(ch.support.SharedConfig().BatchTimeout())
 					continue
 				}
 				for _, batch := range batches {
 					block := ch.support.CreateNextBlock(batch)
-					ch.support.WriteBlock(block, nil)
+					ch.writeBlock(block)
 				}
 				if len(batches) > 0 {
 					timer = nil
 				}
-			default:
-				logger.Panicf("Unsupported msg classification: %v", class)
+			} else {
+				// ConfigMsg
+				if batch := ch.support.BlockCutter().Cut(); batch != nil {
+					block := ch.support.CreateNextBlock(batch)
+					ch.writeBlock(block)
+				}
+
+				if msg.configSeq < ch.support.Sequence() {
+					config, _, err := ch.support.ProcessConfigMsg(msg.configMsg)
+					if err != nil {
+						logger.Warningf("Discarding bad config message: %s", err)
+						continue
+					}
+					msg.configMsg = config
+				}
+
+				block := ch.support.CreateNextBlock([]*cb.Envelope{msg.configMsg})
+				ch.writeConfigBlock(block)
+				timer = nil
 			}
 		case <-timer:
 			//clear the timer
@@ -156,7 +194,7 @@ func (ch *chain) main() {
 			}
 			logger.Debugf("Batch timer expired, creating block")
 			block := ch.support.CreateNextBlock(batch)
-			ch.support.WriteBlock(block, nil)
+			ch.writeBlock(block)
 		case <-ch.exitChan:
 			logger.Debugf("Exiting")
 			return
@@ -0,0 +1,146 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package solo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/orderer/common/msgprocessor"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	cb "github.com/hyperledger/fabric/protos/common"
+)
+
+// mockBlockCutter is a trivial consensus.BlockCutter that never cuts on its
+// own; its pending batch is only emptied by an explicit Cut, such as the one
+// main makes before writing a config block.
+type mockBlockCutter struct {
+	pending []*cb.Envelope
+}
+
+func (m *mockBlockCutter) Ordered(msg *cb.Envelope) ([][]*cb.Envelope, bool) {
+	m.pending = append(m.pending, msg)
+	return nil, true
+}
+
+func (m *mockBlockCutter) Cut() []*cb.Envelope {
+	batch := m.pending
+	m.pending = nil
+	return batch
+}
+
+type mockOrderer struct{}
+
+func (m *mockOrderer) BatchTimeout() time.Duration { return time.Hour }
+func (m *mockOrderer) ConsensusMetadata() []byte   { return nil }
+
+// mockSupport implements consensus.ConsenterSupport, recording enough of
+// what main does with a ConfigMsg to assert on the revalidation branch.
+type mockSupport struct {
+	sequence uint64
+	cutter   *mockBlockCutter
+	orderer  *mockOrderer
+
+	processConfigMsgCalled bool
+	processConfigMsgResult *cb.Envelope
+
+	createdWith        [][]*cb.Envelope
+	configBlockWritten chan struct{}
+}
+
+func (s *mockSupport) ClassifyMsg(chdr *cb.ChannelHeader) (msgprocessor.Classification, error) {
+	return msgprocessor.NormalMsg, nil
+}
+
+func (s *mockSupport) ProcessNormalMsg(env *cb.Envelope) (uint64, error) {
+	return s.sequence, nil
+}
+
+func (s *mockSupport) ProcessConfigUpdateMsg(env *cb.Envelope) (*cb.Envelope, uint64, error) {
+	return nil, s.sequence, nil
+}
+
+func (s *mockSupport) ProcessConfigMsg(env *cb.Envelope) (*cb.Envelope, uint64, error) {
+	s.processConfigMsgCalled = true
+	return s.processConfigMsgResult, s.sequence, nil
+}
+
+func (s *mockSupport) BlockCutter() consensus.BlockCutter { return s.cutter }
+func (s *mockSupport) SharedConfig() consensus.Orderer    { return s.orderer }
+
+func (s *mockSupport) CreateNextBlock(msgs []*cb.Envelope) *cb.Block {
+	s.createdWith = append(s.createdWith, msgs)
+	return &cb.Block{Header: &cb.BlockHeader{}, Metadata: &cb.BlockMetadata{}}
+}
+
+func (s *mockSupport) WriteBlock(block *cb.Block, encodedMetadataValue []byte) {}
+
+func (s *mockSupport) WriteConfigBlock(block *cb.Block, encodedMetadataValue []byte) {
+	s.configBlockWritten <- struct{}{}
+}
+
+func (s *mockSupport) ChainID() string  { return "testchannel" }
+func (s *mockSupport) Height() uint64   { return 0 }
+func (s *mockSupport) Sequence() uint64 { return s.sequence }
+
+// TestChainConfigMsgRevalidation exercises main's ConfigMsg branch: a
+// message whose configSeq is already current is cut as-is, but one computed
+// against a stale configSeq must be re-validated through ProcessConfigMsg
+// before being cut, per the contract described on Chain.Configure.
+func TestChainConfigMsgRevalidation(t *testing.T) {
+	tests := []struct {
+		name             string
+		proposedSeq      uint64
+		currentSeq       uint64
+		expectRevalidate bool
+	}{
+		{name: "current config seq is cut unchanged", proposedSeq: 1, currentSeq: 1, expectRevalidate: false},
+		{name: "stale config seq is revalidated", proposedSeq: 0, currentSeq: 1, expectRevalidate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := &cb.Envelope{Payload: []byte("original")}
+			revalidated := &cb.Envelope{Payload: []byte("revalidated")}
+
+			support := &mockSupport{
+				sequence:               tt.currentSeq,
+				cutter:                 &mockBlockCutter{},
+				orderer:                &mockOrderer{},
+				processConfigMsgResult: revalidated,
+				configBlockWritten:     make(chan struct{}, 1),
+			}
+
+			ch := newChain(support)
+			ch.Start()
+			defer ch.Halt()
+
+			if err := ch.Configure(nil, original, tt.proposedSeq); err != nil {
+				t.Fatalf("Configure returned error: %s", err)
+			}
+
+			select {
+			case <-support.configBlockWritten:
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for config block to be written")
+			}
+
+			if support.processConfigMsgCalled != tt.expectRevalidate {
+				t.Errorf("ProcessConfigMsg called = %v, want %v", support.processConfigMsgCalled, tt.expectRevalidate)
+			}
+
+			want := original
+			if tt.expectRevalidate {
+				want = revalidated
+			}
+			got := support.createdWith[len(support.createdWith)-1]
+			if len(got) != 1 || got[0] != want {
+				t.Errorf("block cut from wrong envelope: got %v, want [%v]", got, want)
+			}
+		})
+	}
+}